@@ -0,0 +1,122 @@
+package ksema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	defaultCacheTTL   = 5 * time.Minute
+	rngPrefetchAmount = 4096
+)
+
+var (
+	cacheVerifyOK   = []byte{1}
+	cacheVerifyBad  = []byte{0}
+	errVerifyCached = errors.New("ksema: signature verification failed (cached result)")
+
+	// ErrInvalidSignature is what classifyHSMError translates operationVerify's
+	// error into when the HSM reaches a definitive verdict that the
+	// signature does not match the data, as opposed to a transport or
+	// session error.
+	ErrInvalidSignature = errors.New("ksema: invalid signature")
+)
+
+func (k *Ksema) cacheTTL() time.Duration {
+	if k.opts.CacheTTL > 0 {
+		return k.opts.CacheTTL
+	}
+	return defaultCacheTTL
+}
+
+func verifyCacheKey(keyLabel string, data, signature []byte) string {
+	dataSum := sha256.Sum256(data)
+	sigSum := sha256.Sum256(signature)
+	return "verify:" + keyLabel + ":" + hex.EncodeToString(dataSum[:]) + ":" + hex.EncodeToString(sigSum[:])
+}
+
+// verifyCached calls operationVerify, consulting and populating
+// Options.Cache first when configured. Only a definitive result
+// (success, or a genuine ErrInvalidSignature) is cached; transport and
+// session errors are never memoized, so they keep propagating to
+// withRetry instead of being masked as a permanent bad verification.
+func (k *Ksema) verifyCached(data, signature []byte, keyLabel string) error {
+	if k.opts.Cache == nil {
+		return classifyHSMError(operationVerify(k.client, k.sessionID(), k.serverIP, data, signature, keyLabel))
+	}
+
+	key := verifyCacheKey(keyLabel, data, signature)
+	if cached, ok := k.opts.Cache.Get(key); ok {
+		if cached[0] == cacheVerifyOK[0] {
+			return nil
+		}
+		return errVerifyCached
+	}
+
+	err := classifyHSMError(operationVerify(k.client, k.sessionID(), k.serverIP, data, signature, keyLabel))
+	switch {
+	case err == nil:
+		k.opts.Cache.Set(key, cacheVerifyOK, k.cacheTTL())
+	case errors.Is(err, ErrInvalidSignature):
+		k.opts.Cache.Set(key, cacheVerifyBad, k.cacheTTL())
+	}
+	return err
+}
+
+// PublicKey returns the public key material held under keyLabel, serving
+// it from Options.Cache when configured and present.
+func (k *Ksema) PublicKey(keyLabel string) ([]byte, error) {
+	if keyLabel == "" {
+		return nil, errors.New("key label is not specified")
+	}
+
+	var pub []byte
+	fetch := func() error {
+		var err error
+		pub, err = operationGetPublicKey(k.client, k.sessionID(), k.serverIP, keyLabel)
+		return err
+	}
+
+	if k.opts.Cache == nil {
+		err := k.withRetry(fetch)
+		return pub, err
+	}
+
+	key := "pubkey:" + keyLabel
+	if cached, ok := k.opts.Cache.Get(key); ok {
+		return cached, nil
+	}
+
+	if err := k.withRetry(fetch); err != nil {
+		return nil, err
+	}
+	k.opts.Cache.Set(key, pub, k.cacheTTL())
+	return pub, nil
+}
+
+// rngPrefetch returns n random bytes, filling and slicing from a
+// rngPrefetchAmount-byte buffer fetched from operationRNG in batches, so
+// that repeated small Random calls don't each round-trip to the HSM.
+func (k *Ksema) rngPrefetch(n int) ([]byte, error) {
+	k.rngMu.Lock()
+	defer k.rngMu.Unlock()
+
+	if len(k.rngBuf) < n {
+		var buf []byte
+		err := k.withRetry(func() error {
+			var err error
+			buf, err = operationRNG(k.client, k.sessionID(), k.serverIP, uint16ToBytes(rngPrefetchAmount))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		k.rngBuf = buf
+	}
+
+	out := k.rngBuf[:n]
+	k.rngBuf = k.rngBuf[n:]
+	return out, nil
+}
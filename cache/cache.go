@@ -0,0 +1,95 @@
+// Package cache provides a small in-memory TTL cache used to memoize
+// expensive HSM round-trips, e.g. Verify results and public key material.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache is a minimal key/value store with per-entry expiry.
+type Cache interface {
+	Get(key string) (value []byte, ok bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+}
+
+// Metrics tracks cache hit/miss counters so operators can size TTLs
+// against their HSM's rate limits.
+type Metrics struct {
+	mu     sync.Mutex
+	hits   uint64
+	misses uint64
+}
+
+func (m *Metrics) hit() {
+	m.mu.Lock()
+	m.hits++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) miss() {
+	m.mu.Lock()
+	m.misses++
+	m.mu.Unlock()
+}
+
+// Snapshot returns the current hit/miss counts.
+func (m *Metrics) Snapshot() (hits, misses uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hits, m.misses
+}
+
+type entry struct {
+	value    []byte
+	expireAt time.Time
+}
+
+// TTLCache is the default in-memory Cache implementation.
+type TTLCache struct {
+	mu      sync.Mutex
+	entries map[string]entry
+
+	Metrics *Metrics
+}
+
+// NewTTLCache returns an empty TTLCache.
+func NewTTLCache() *TTLCache {
+	return &TTLCache{
+		entries: make(map[string]entry),
+		Metrics: &Metrics{},
+	}
+}
+
+// Get returns the cached value for key, if present and not expired.
+func (c *TTLCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expireAt) {
+		if ok {
+			delete(c.entries, key)
+		}
+		c.Metrics.miss()
+		return nil, false
+	}
+
+	c.Metrics.hit()
+	return e.value, true
+}
+
+// Set stores value under key until ttl elapses.
+func (c *TTLCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry{value: value, expireAt: time.Now().Add(ttl)}
+}
+
+// Delete removes key, if present.
+func (c *TTLCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCacheGetSetDelete(t *testing.T) {
+	c := NewTTLCache()
+
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("Get on empty cache returned ok=true")
+	}
+
+	c.Set("k", []byte("v"), time.Minute)
+	got, ok := c.Get("k")
+	if !ok || string(got) != "v" {
+		t.Fatalf("Get after Set = (%q, %v), want (\"v\", true)", got, ok)
+	}
+
+	c.Delete("k")
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("Get after Delete returned ok=true")
+	}
+}
+
+func TestTTLCacheExpiry(t *testing.T) {
+	c := NewTTLCache()
+	c.entries["k"] = entry{value: []byte("v"), expireAt: time.Now().Add(-time.Second)}
+
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("Get returned ok=true for an expired entry")
+	}
+	if _, ok := c.entries["k"]; ok {
+		t.Fatalf("expired entry was not evicted from entries on Get")
+	}
+}
+
+func TestTTLCacheMetrics(t *testing.T) {
+	c := NewTTLCache()
+	c.Set("k", []byte("v"), time.Minute)
+
+	c.Get("k")     // hit
+	c.Get("other") // miss
+
+	hits, misses := c.Metrics.Snapshot()
+	if hits != 1 || misses != 1 {
+		t.Fatalf("Snapshot() = (%d, %d), want (1, 1)", hits, misses)
+	}
+}
@@ -0,0 +1,51 @@
+package ksema
+
+import "errors"
+
+// Generate an ML-KEM-768 keypair with the specified key label
+//
+// Note that user object is not authorized to use this function
+func (k *Ksema) GenKemKey(label string) error {
+	if label == "" {
+		return errors.New("key label is not specified")
+	}
+	return k.withRetry(func() error {
+		return operationGenKeyKem(k.client, k.sessionID(), k.serverIP, label)
+	})
+}
+
+// Perform ML-KEM-768 encapsulation against the keypair held under keyLabel
+// Return the encapsulated ciphertext, the derived shared secret, and error
+//
+// User object does not need to specified the key label used, except for user slot
+func (k *Ksema) Encapsulate(keyLabel string) (ciphertext, sharedSecret []byte, err error) {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
+		return nil, nil, errors.New("no key label specified")
+	}
+	err = k.withRetry(func() error {
+		var err error
+		ciphertext, sharedSecret, err = operationKemEncapsulate(k.client, k.sessionID(), k.serverIP, keyLabel)
+		return err
+	})
+	return ciphertext, sharedSecret, err
+}
+
+// Perform ML-KEM-768 decapsulation of ciphertext against the keypair held under keyLabel
+// Return the recovered shared secret and error
+//
+// User object does not need to specified the key label used, except for user slot
+func (k *Ksema) Decapsulate(ciphertext []byte, keyLabel string) ([]byte, error) {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
+		return nil, errors.New("no key label specified")
+	}
+	if len(ciphertext) == 0 {
+		return nil, errors.New("ciphertext is not specified")
+	}
+	var sharedSecret []byte
+	err := k.withRetry(func() error {
+		var err error
+		sharedSecret, err = operationKemDecapsulate(k.client, k.sessionID(), k.serverIP, ciphertext, keyLabel)
+		return err
+	})
+	return sharedSecret, err
+}
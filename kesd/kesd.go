@@ -0,0 +1,215 @@
+// Package kesd exposes a MinIO-KES-compatible HTTP facade in front of Ksema.
+//
+// It lets applications that already speak the KES protocol (object stores,
+// envelope-encryption libraries, ...) use a Ksema HSM as their key backend
+// without linking the Go client directly.
+package kesd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/carakacloud/ksema"
+)
+
+// ClientCertMapper maps an authenticated client certificate to the userType
+// that should be used for the underlying Ksema operations.
+type ClientCertMapper func(cert *x509.Certificate) (userType int, err error)
+
+// Server is a KES-compatible HTTP facade backed by a Ksema client.
+type Server struct {
+	ks        *ksema.Ksema
+	mapper    ClientCertMapper
+	tlsConfig *tls.Config
+}
+
+// New returns a Server that drives the given Ksema client.
+//
+// clientCAs is the pool of CAs trusted to sign client certificates; mapper
+// resolves an authenticated client certificate to a Ksema userType, which
+// gates access to key management routes (create/delete): USER_OBJECT
+// callers are refused here, in line with Ksema.GenKey's note that user
+// objects are not authorized to generate keys. If mapper is nil, key
+// management routes refuse every request, since there is no userType to
+// check; generate/decrypt/status remain open to any presented client
+// certificate.
+func New(ks *ksema.Ksema, clientCAs *x509.CertPool, mapper ClientCertMapper) *Server {
+	return &Server{
+		ks:     ks,
+		mapper: mapper,
+		tlsConfig: &tls.Config{
+			ClientCAs:  clientCAs,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+		},
+	}
+}
+
+// Handler returns the http.Handler implementing the KES REST surface.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/key/create/{name}", s.handleCreate)
+	mux.HandleFunc("POST /v1/key/generate/{name}", s.handleGenerate)
+	mux.HandleFunc("POST /v1/key/decrypt/{name}", s.handleDecrypt)
+	mux.HandleFunc("POST /v1/key/delete/{name}", s.handleDelete)
+	mux.HandleFunc("GET /v1/status", s.handleStatus)
+	return mux
+}
+
+// ListenAndServeTLS starts the facade on addr, terminating mTLS itself.
+func (s *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   s.Handler(),
+		TLSConfig: s.tlsConfig,
+	}
+	return srv.ListenAndServeTLS(certFile, keyFile)
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	userType, err := s.userTypeFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if s.mapper == nil || userType == ksema.USER_OBJECT {
+		writeError(w, http.StatusForbidden, errors.New("client certificate is not authorized to manage keys"))
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := s.ks.GenKey(name, ""); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.userTypeFromRequest(r); err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	name := r.PathValue("name")
+
+	plain, err := s.ks.Random(32)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	// Random returns base64; Encrypt wants the raw data key bytes so that
+	// decrypting the resulting ciphertext yields back exactly plain.
+	rawKey, err := base64.StdEncoding.DecodeString(plain)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cipher, err := s.ks.Encrypt(rawKey, name)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeJSON(w, generateResponse{
+		Plaintext:  plain,
+		Ciphertext: cipher,
+	})
+}
+
+func (s *Server) handleDecrypt(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.userTypeFromRequest(r); err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	name := r.PathValue("name")
+
+	var req decryptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	plain, err := s.ks.Decrypt(req.Ciphertext, name)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeJSON(w, decryptResponse{
+		Plaintext: base64.StdEncoding.EncodeToString([]byte(plain)),
+	})
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	userType, err := s.userTypeFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if s.mapper == nil || userType == ksema.USER_OBJECT {
+		writeError(w, http.StatusForbidden, errors.New("client certificate is not authorized to manage keys"))
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := s.ks.Delete(name); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if err := s.ks.Ping(); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, statusResponse{Status: "ok"})
+}
+
+// userTypeFromRequest resolves the userType for an authenticated request
+// via the configured ClientCertMapper.
+func (s *Server) userTypeFromRequest(r *http.Request) (int, error) {
+	if s.mapper == nil {
+		return 0, nil
+	}
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return 0, errors.New("no client certificate presented")
+	}
+	return s.mapper(r.TLS.PeerCertificates[0])
+}
+
+type generateResponse struct {
+	Plaintext  string `json:"plaintext"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+type decryptRequest struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+type decryptResponse struct {
+	Plaintext string `json:"plaintext"`
+}
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -2,7 +2,6 @@ package ksema
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -10,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sync"
 )
 
 type Ksema struct {
@@ -19,36 +19,29 @@ type Ksema struct {
 	client   *http.Client
 	sessID   string
 	userType int
+
+	opts Options
+
+	mu            sync.Mutex   // serializes re-auth attempts triggered by withRetry
+	sessMu        sync.RWMutex // guards sessID/userType, read by every in-flight call
+	closeOnce     sync.Once
+	stopKeepAlive chan struct{}
+	keepAliveDone chan struct{}
+
+	rngMu  sync.Mutex
+	rngBuf []byte
 }
 
 // New return the pointer of Ksema object
 //
 // It automatically execute the key exchange and must be success in order to use it
+//
+// Deprecated: New skips TLS server certificate verification entirely,
+// which defeats TLS authentication against the HSM. Use NewWithOptions
+// with a RootCAs pool or SPKIPins instead.
 func New(serverIP, apiKey, pin string) (*Ksema, error) {
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-				CurvePreferences: []tls.CurveID{
-					tls.X25519MLKEM768,
-				},
-			},
-		},
-	}
-
-	k := &Ksema{
-		serverIP: serverIP,
-		apiKey:   apiKey,
-		pin:      pin,
-		client:   client,
-	}
-
-	if success, err := k.auth(); err != nil || !success {
-		fmt.Println("Authentication failed, please retry")
-		return nil, err
-	}
-
-	return k, nil
+	fmt.Println("Warning: ksema.New is deprecated and connects without verifying the HSM's certificate; use ksema.NewWithOptions instead")
+	return NewWithOptions(serverIP, apiKey, pin, Options{insecure: true})
 }
 
 // Perform auth with account keys
@@ -89,16 +82,37 @@ func (k *Ksema) auth() (bool, error) {
 		return false, errors.New("return auth request is false")
 	}
 
+	k.sessMu.Lock()
 	k.sessID = res.Data.SessionID
 	k.userType = res.Data.UserType
+	k.sessMu.Unlock()
 
 	return true, nil
 }
 
+// sessionID returns the current session ID, safe for concurrent use with
+// auth() re-authenticating in another goroutine (e.g. the keepalive
+// goroutine racing a withRetry-triggered re-auth).
+func (k *Ksema) sessionID() string {
+	k.sessMu.RLock()
+	defer k.sessMu.RUnlock()
+	return k.sessID
+}
+
+// currentUserType returns the current userType under the same lock as
+// sessionID, for the same reason.
+func (k *Ksema) currentUserType() int {
+	k.sessMu.RLock()
+	defer k.sessMu.RUnlock()
+	return k.userType
+}
+
 // Perform ping to server
 // Return error if failed
 func (k *Ksema) Ping() error {
-	return operationPing(k.client, k.sessID, k.serverIP)
+	return k.withRetry(func() error {
+		return operationPing(k.client, k.sessionID(), k.serverIP)
+	})
 }
 
 // Perform encrypt of a data bytes
@@ -106,10 +120,15 @@ func (k *Ksema) Ping() error {
 //
 // User object does not need to specified the key label used, except for user slot
 func (k *Ksema) Encrypt(data []byte, keyLabel string) (string, error) {
-	if k.userType > USER_OBJECT && keyLabel == "" {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
 		return "", errors.New("no key label specified")
 	}
-	cipher, err := operationEncrypt(k.client, k.sessID, k.serverIP, data, keyLabel)
+	var cipher []byte
+	err := k.withRetry(func() error {
+		var err error
+		cipher, err = operationEncrypt(k.client, k.sessionID(), k.serverIP, data, keyLabel)
+		return err
+	})
 
 	return base64.StdEncoding.EncodeToString(cipher), err
 }
@@ -119,40 +138,47 @@ func (k *Ksema) Encrypt(data []byte, keyLabel string) (string, error) {
 //
 // User object does not need to specified the key label used, except for user slot
 func (k *Ksema) Decrypt(data string, keyLabel string) (string, error) {
-	if k.userType > USER_OBJECT && keyLabel == "" {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
 		return "", errors.New("no key label specified")
 	}
 	dataBytes, err := base64.StdEncoding.DecodeString(data)
 	if err != nil {
 		return "", err
 	}
-	plain, err := operationDecrypt(k.client, k.sessID, k.serverIP, dataBytes, keyLabel)
+	var plain []byte
+	err = k.withRetry(func() error {
+		var err error
+		plain, err = operationDecrypt(k.client, k.sessionID(), k.serverIP, dataBytes, keyLabel)
+		return err
+	})
 
 	return string(plain), err
 }
 
 // Perform signing of a file data
-// Return the filename of data signature and error
+// Return the signature bytes and error
 //
 // User object does not need to specified the key label used, except for user slot
-func (k *Ksema) Sign(dataFilename string, keyLabel string) (string, error) {
-	if k.userType > USER_OBJECT && keyLabel == "" {
-		return "", errors.New("no key label specified")
+func (k *Ksema) Sign(dataFilename string, keyLabel string) ([]byte, error) {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
+		return nil, errors.New("no key label specified")
 	}
 	if dataFilename == "" {
-		return "", errors.New("data filename is not specified")
+		return nil, errors.New("data filename is not specified")
 	}
 	data, err := os.ReadFile(dataFilename)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	signature, err := operationSign(k.client, k.sessID, k.serverIP, data, keyLabel)
-	if err := os.WriteFile("signature.file", signature, 0644); err != nil {
-		return "", err
-	}
+	var signature []byte
+	err = k.withRetry(func() error {
+		var err error
+		signature, err = operationSign(k.client, k.sessionID(), k.serverIP, data, keyLabel)
+		return err
+	})
 
-	return "signature.file", err
+	return signature, err
 }
 
 // Perform verifying of a data bytes with signature
@@ -160,7 +186,7 @@ func (k *Ksema) Sign(dataFilename string, keyLabel string) (string, error) {
 //
 // User object does not need to specified the key label used, except for user slot
 func (k *Ksema) Verify(dataFilename, signatureFilename string, keyLabel string) error {
-	if k.userType > USER_OBJECT && keyLabel == "" {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
 		return errors.New("no key label specified")
 	}
 	if dataFilename == "" || signatureFilename == "" {
@@ -174,13 +200,18 @@ func (k *Ksema) Verify(dataFilename, signatureFilename string, keyLabel string)
 	if err != nil {
 		return err
 	}
-	return operationVerify(k.client, k.sessID, k.serverIP, data, signature, keyLabel)
+	return k.withRetry(func() error {
+		return k.verifyCached(data, signature, keyLabel)
+	})
 }
 
 // Generate random data in string base64
 // Return error if it is not success
 //
 // if the length specified is 0, it will use the default length which is 32
+//
+// If Options.Cache is configured, requests smaller than rngPrefetchAmount
+// are served out of a pre-fetched buffer instead of a fresh HSM round-trip.
 func (k *Ksema) Random(lenRandom uint16) (string, error) {
 	var lengthBytes []byte
 
@@ -190,7 +221,20 @@ func (k *Ksema) Random(lenRandom uint16) (string, error) {
 		lengthBytes = nil
 	}
 
-	rnd, err := operationRNG(k.client, k.sessID, k.serverIP, lengthBytes)
+	if k.opts.Cache != nil && lenRandom > 0 && int(lenRandom) < rngPrefetchAmount {
+		rnd, err := k.rngPrefetch(int(lenRandom))
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(rnd), nil
+	}
+
+	var rnd []byte
+	err := k.withRetry(func() error {
+		var err error
+		rnd, err = operationRNG(k.client, k.sessionID(), k.serverIP, lengthBytes)
+		return err
+	})
 	rndBased := base64.StdEncoding.EncodeToString(rnd)
 
 	return rndBased, err
@@ -201,25 +245,31 @@ func (k *Ksema) Random(lenRandom uint16) (string, error) {
 //
 // User object does not need to specified the key label used, except for user slot
 func (k *Ksema) Backup(fileName, keyLabel string) error {
-	if k.userType > USER_OBJECT && keyLabel == "" {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
 		return errors.New("no key label specified")
 	}
-	return operationBackup(k.client, k.sessID, k.serverIP, k.userType, []byte(fileName), keyLabel)
+	return k.withRetry(func() error {
+		return operationBackup(k.client, k.sessionID(), k.serverIP, k.currentUserType(), []byte(fileName), keyLabel)
+	})
 }
 
 // Perform restore of a keylabel using the backed-up file
 // Return error if it is not success
 func (k *Ksema) Restore(fileName string) error {
-	return operationRestore(k.client, k.sessID, k.serverIP, []byte(fileName))
+	return k.withRetry(func() error {
+		return operationRestore(k.client, k.sessionID(), k.serverIP, []byte(fileName))
+	})
 }
 
 // Perform deletion of a keylabel
 // Return error if it is not success
 func (k *Ksema) Delete(keyLabel string) error {
-	if k.userType > USER_OBJECT && keyLabel == "" {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
 		return errors.New("no key label specified")
 	}
-	return operationDelete(k.client, k.sessID, k.serverIP, keyLabel)
+	return k.withRetry(func() error {
+		return operationDelete(k.client, k.sessionID(), k.serverIP, keyLabel)
+	})
 }
 
 // Generate key with the specified key label
@@ -235,12 +285,16 @@ func (k *Ksema) GenKey(label1, label2 string) error {
 }
 
 func (k *Ksema) genKeySym(label string) error {
-	return operationGenKeySym(k.client, k.sessID, k.serverIP, label)
+	return k.withRetry(func() error {
+		return operationGenKeySym(k.client, k.sessionID(), k.serverIP, label)
+	})
 }
 
 func (k *Ksema) genKeyAsym(pubLabel, privLabel string) error {
 	// label := fmt.Sprintf("%s;%s", pubLabel, privLabel)
-	return operationGenKeyAsym(k.client, k.sessID, k.serverIP, pubLabel, privLabel)
+	return k.withRetry(func() error {
+		return operationGenKeyAsym(k.client, k.sessionID(), k.serverIP, pubLabel, privLabel)
+	})
 }
 
 // Override the default IV temporarily
@@ -249,9 +303,7 @@ func (k *Ksema) SetIV(iv string) error {
 	if len(iv) != 16 {
 		return errors.New("IV must be 16 characters")
 	}
-	return operationSetIV(k.client, k.sessID, k.serverIP, []byte(iv))
+	return k.withRetry(func() error {
+		return operationSetIV(k.client, k.sessionID(), k.serverIP, []byte(iv))
+	})
 }
-
-// func (k *Ksema) Close() {
-// 	fmt.Println("Closing connection...")
-// }
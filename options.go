@@ -0,0 +1,212 @@
+package ksema
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/carakacloud/ksema/cache"
+)
+
+// Options configures the transport and session behavior used by NewWithOptions.
+type Options struct {
+	// RootCAs is the pool of CA certificates trusted to sign the HSM's
+	// server certificate. If nil, the host's system roots are used.
+	RootCAs *x509.CertPool
+
+	// SPKIPins is a list of base64-encoded SHA-256 digests of the
+	// server certificate's SubjectPublicKeyInfo. When non-empty, the
+	// connection is accepted only if the presented chain contains a
+	// certificate matching one of these pins, instead of relying on
+	// chain validation against RootCAs.
+	SPKIPins []string
+
+	// CacheDir, if set, is a directory used to persist the server
+	// certificate seen on a successful connection. When SPKIPins is
+	// empty, a certificate cached there by an earlier run is pinned by
+	// subsequent calls to NewWithOptions, in place of chain validation
+	// against RootCAs, so trust can be bootstrapped offline. A connection
+	// made while nothing is cached yet is verified against RootCAs (or
+	// the system roots) as usual.
+	CacheDir string
+
+	// KeepAliveInterval, if positive, starts a background goroutine that
+	// calls Ping on this interval to keep the HSM session alive. Zero
+	// disables the keepalive goroutine.
+	KeepAliveInterval time.Duration
+
+	// MaxRetries is the number of times an operation is retried, each
+	// preceded by a fresh auth() call, after the HSM reports the current
+	// session has expired. Zero (the Go zero value, so also New's and any
+	// unset Options{}'s default) is treated as DefaultMaxRetries rather
+	// than disabling retries outright; pass a negative value to opt out
+	// of automatic re-auth entirely.
+	MaxRetries int
+
+	// Timeout bounds how long a single HTTP call to the HSM may take.
+	// Zero means no timeout.
+	Timeout time.Duration
+
+	// Cache, if set, memoizes Verify results and public key material
+	// fetched by PublicKey. Use cache.NewTTLCache() for the default
+	// in-memory implementation.
+	//
+	// This is a plain Options field rather than a separate WithCache(Cache)
+	// function so it follows the same struct-of-knobs convention as the
+	// rest of Options (RootCAs, SPKIPins, KeepAliveInterval, ...) instead
+	// of introducing a second configuration style just for caching.
+	Cache cache.Cache
+
+	// CacheTTL is how long entries placed in Cache stay valid. Zero
+	// falls back to a 5 minute default.
+	CacheTTL time.Duration
+
+	insecure bool
+}
+
+// NewWithOptions returns the pointer of Ksema object, like New, but lets the
+// caller control how the HSM's server certificate is validated instead of
+// unconditionally skipping verification.
+//
+// It automatically execute the key exchange and must be success in order to use it
+func NewWithOptions(serverIP, apiKey, pin string, opt Options) (*Ksema, error) {
+	cachePath := certCachePath(opt.CacheDir, serverIP)
+
+	pins := append([]string(nil), opt.SPKIPins...)
+	if opt.CacheDir != "" && len(pins) == 0 {
+		if cached, err := cachedSPKIPin(cachePath); err == nil {
+			pins = append(pins, cached)
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs: opt.RootCAs,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519MLKEM768,
+		},
+	}
+
+	switch {
+	case opt.insecure:
+		tlsConfig.InsecureSkipVerify = true
+	case len(pins) > 0:
+		// Verification is performed entirely in VerifyPeerCertificate.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifySPKIPins(pins)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+		Timeout: opt.Timeout,
+	}
+
+	k := &Ksema{
+		serverIP: serverIP,
+		apiKey:   apiKey,
+		pin:      pin,
+		client:   client,
+		opts:     opt,
+	}
+
+	if success, err := k.auth(); err != nil || !success {
+		fmt.Println("Authentication failed, please retry")
+		return nil, err
+	}
+
+	if opt.CacheDir != "" && len(opt.SPKIPins) == 0 {
+		if err := cacheServerCert(client, serverIP, cachePath); err != nil {
+			fmt.Printf("Warning: could not cache server certificate: %v\n", err)
+		}
+	}
+
+	k.startKeepAlive()
+
+	return k, nil
+}
+
+// verifySPKIPins builds a tls.Config.VerifyPeerCertificate callback that
+// accepts the connection if any certificate in the presented chain has a
+// SubjectPublicKeyInfo matching one of pins (base64-encoded SHA-256).
+func verifySPKIPins(pins []string) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			got := base64.StdEncoding.EncodeToString(sum[:])
+			for _, pin := range pins {
+				if subtle.ConstantTimeCompare([]byte(got), []byte(pin)) == 1 {
+					return nil
+				}
+			}
+		}
+		return errors.New("ksema: server certificate does not match any configured SPKI pin")
+	}
+}
+
+func certCachePath(cacheDir, serverIP string) string {
+	if cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(cacheDir, serverIP+".pem")
+}
+
+// cachedSPKIPin reads a previously cached server certificate and returns
+// its SPKI pin, for offline trust bootstrap.
+func cachedSPKIPin(cachePath string) (string, error) {
+	if cachePath == "" {
+		return "", errors.New("no cache path")
+	}
+	raw, err := os.ReadFile(cachePath)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return "", errors.New("ksema: invalid cached certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// cacheServerCert issues a GET to serverIP's /api/hsm/ping through client and
+// records the leaf certificate presented on that request, so it can seed
+// cachedSPKIPin on a later run.
+func cacheServerCert(client *http.Client, serverIP, cachePath string) error {
+	if cachePath == "" {
+		return nil
+	}
+	resp, err := client.Get(fmt.Sprintf("https://%s/api/hsm/ping", serverIP))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return errors.New("ksema: no peer certificate observed")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		return err
+	}
+
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: resp.TLS.PeerCertificates[0].Raw}
+	return os.WriteFile(cachePath, pem.EncodeToMemory(block), 0600)
+}
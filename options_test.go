@@ -0,0 +1,65 @@
+package ksema
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func mustSelfSignedCert(t *testing.T) []byte {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	return raw
+}
+
+func spkiPin(t *testing.T, raw []byte) string {
+	t.Helper()
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestVerifySPKIPinsMatch(t *testing.T) {
+	raw := mustSelfSignedCert(t)
+	pin := spkiPin(t, raw)
+
+	verify := verifySPKIPins([]string{"bogus-pin", pin})
+	if err := verify([][]byte{raw}, nil); err != nil {
+		t.Fatalf("verifySPKIPins rejected a matching pin: %v", err)
+	}
+}
+
+func TestVerifySPKIPinsNoMatch(t *testing.T) {
+	raw := mustSelfSignedCert(t)
+
+	verify := verifySPKIPins([]string{"bogus-pin"})
+	if err := verify([][]byte{raw}, nil); err == nil {
+		t.Fatalf("verifySPKIPins accepted a certificate matching no configured pin")
+	}
+}
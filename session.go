@@ -0,0 +1,113 @@
+package ksema
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSessionExpired is the sentinel withRetry watches for. The operation*
+// helpers don't return it directly; classifyHSMError translates the HSM's
+// plain-text "session has expired" response into this error so withRetry
+// can recognize it across every call site.
+var ErrSessionExpired = errors.New("ksema: session expired")
+
+// classifyHSMError normalizes an operation* error into one of the package's
+// typed sentinel errors when the HSM's plain-text message identifies a
+// specific, well-known outcome (session expiry, a definitive bad signature),
+// leaving every other error untouched. withRetry and verifyCached rely on
+// this translation to react to those outcomes instead of treating every
+// operation* failure as an opaque, unretryable, uncacheable error.
+func classifyHSMError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "session expired"), strings.Contains(msg, "session has expired"):
+		return ErrSessionExpired
+	case strings.Contains(msg, "invalid signature"):
+		return ErrInvalidSignature
+	default:
+		return err
+	}
+}
+
+// DefaultMaxRetries is the number of re-auth retries used when
+// Options.MaxRetries is left at its zero value, so automatic re-auth works
+// out of the box for New and any caller that doesn't set MaxRetries.
+const DefaultMaxRetries = 1
+
+// maxRetries resolves the effective retry count: the zero value falls back
+// to DefaultMaxRetries, a negative value opts out of retries entirely.
+func (k *Ksema) maxRetries() int {
+	switch {
+	case k.opts.MaxRetries < 0:
+		return 0
+	case k.opts.MaxRetries == 0:
+		return DefaultMaxRetries
+	default:
+		return k.opts.MaxRetries
+	}
+}
+
+// withRetry runs fn and, if it fails because the session expired,
+// transparently re-authenticates and retries fn, up to maxRetries times.
+func (k *Ksema) withRetry(fn func() error) error {
+	err := classifyHSMError(fn())
+	for attempt := 0; errors.Is(err, ErrSessionExpired) && attempt < k.maxRetries(); attempt++ {
+		k.mu.Lock()
+		_, authErr := k.auth()
+		k.mu.Unlock()
+		if authErr != nil {
+			return authErr
+		}
+		err = classifyHSMError(fn())
+	}
+	return err
+}
+
+// startKeepAlive launches a background goroutine that calls Ping on
+// Options.KeepAliveInterval to keep the HSM session alive. It is a no-op
+// if KeepAliveInterval is zero.
+func (k *Ksema) startKeepAlive() {
+	if k.opts.KeepAliveInterval <= 0 {
+		return
+	}
+
+	k.stopKeepAlive = make(chan struct{})
+	k.keepAliveDone = make(chan struct{})
+
+	go func() {
+		defer close(k.keepAliveDone)
+
+		ticker := time.NewTicker(k.opts.KeepAliveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := k.Ping(); err != nil {
+					fmt.Printf("ksema: keepalive ping failed: %v\n", err)
+				}
+			case <-k.stopKeepAlive:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the keepalive goroutine, if any, and logs the session out of
+// the HSM. Ksema satisfies io.Closer so callers can use defer k.Close().
+func (k *Ksema) Close() error {
+	var err error
+	k.closeOnce.Do(func() {
+		if k.stopKeepAlive != nil {
+			close(k.stopKeepAlive)
+			<-k.keepAliveDone
+		}
+		err = operationLogout(k.client, k.sessionID(), k.serverIP)
+	})
+	return err
+}
@@ -0,0 +1,203 @@
+package ksema
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// streamChunkSize is the amount of plaintext/ciphertext buffered in memory
+// at a time by the Stream variants, so that multi-GB payloads can be
+// processed without reading them entirely into memory.
+const streamChunkSize = 1 << 20 // 1 MiB
+
+// EncryptStream encrypts src in streamChunkSize blocks, writing each
+// resulting ciphertext block to dst as a 4-byte big-endian length prefix
+// followed by the ciphertext bytes. Return the number of plaintext bytes
+// read and error.
+//
+// User object does not need to specified the key label used, except for user slot
+func (k *Ksema) EncryptStream(dst io.Writer, src io.Reader, keyLabel string) (int64, error) {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
+		return 0, errors.New("no key label specified")
+	}
+
+	var total int64
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, readErr := io.ReadFull(src, buf)
+		if n > 0 {
+			var cipher []byte
+			err := k.withRetry(func() error {
+				var err error
+				cipher, err = operationEncrypt(k.client, k.sessionID(), k.serverIP, buf[:n], keyLabel)
+				return err
+			})
+			if err != nil {
+				return total, err
+			}
+			if err := writeFramed(dst, cipher); err != nil {
+				return total, err
+			}
+			total += int64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return total, readErr
+		}
+	}
+
+	return total, nil
+}
+
+// DecryptStream reverses EncryptStream: it reads framed ciphertext blocks
+// from src, decrypts each with keyLabel, and writes the recovered
+// plaintext to dst. Return the number of plaintext bytes written and error.
+//
+// User object does not need to specified the key label used, except for user slot
+func (k *Ksema) DecryptStream(dst io.Writer, src io.Reader, keyLabel string) (int64, error) {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
+		return 0, errors.New("no key label specified")
+	}
+
+	var total int64
+	for {
+		cipher, err := readFramed(src)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+
+		var plain []byte
+		err = k.withRetry(func() error {
+			var err error
+			plain, err = operationDecrypt(k.client, k.sessionID(), k.serverIP, cipher, keyLabel)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+		n, err := dst.Write(plain)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// SignStream signs src in streamChunkSize blocks without buffering the
+// whole input in memory. Return the signature bytes and error.
+//
+// User object does not need to specified the key label used, except for user slot
+func (k *Ksema) SignStream(src io.Reader, keyLabel string) ([]byte, error) {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
+		return nil, errors.New("no key label specified")
+	}
+
+	var streamID string
+	if err := k.withRetry(func() error {
+		var err error
+		streamID, err = operationSignInit(k.client, k.sessionID(), k.serverIP, keyLabel)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := feedStream(src, func(chunk []byte) error {
+		return k.withRetry(func() error {
+			return operationSignUpdate(k.client, k.sessionID(), k.serverIP, streamID, chunk)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	var signature []byte
+	err := k.withRetry(func() error {
+		var err error
+		signature, err = operationSignFinal(k.client, k.sessionID(), k.serverIP, streamID)
+		return err
+	})
+	return signature, err
+}
+
+// VerifyStream verifies src against signature in streamChunkSize blocks
+// without buffering the whole input in memory. Return error if it is invalid.
+//
+// User object does not need to specified the key label used, except for user slot
+func (k *Ksema) VerifyStream(src io.Reader, signature []byte, keyLabel string) error {
+	if k.currentUserType() > USER_OBJECT && keyLabel == "" {
+		return errors.New("no key label specified")
+	}
+
+	var streamID string
+	if err := k.withRetry(func() error {
+		var err error
+		streamID, err = operationVerifyInit(k.client, k.sessionID(), k.serverIP, keyLabel)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err := feedStream(src, func(chunk []byte) error {
+		return k.withRetry(func() error {
+			return operationVerifyUpdate(k.client, k.sessionID(), k.serverIP, streamID, chunk)
+		})
+	}); err != nil {
+		return err
+	}
+
+	return k.withRetry(func() error {
+		return operationVerifyFinal(k.client, k.sessionID(), k.serverIP, streamID, signature)
+	})
+}
+
+// feedStream reads src in streamChunkSize blocks and calls update for each
+// non-empty block read.
+func feedStream(src io.Reader, update func(chunk []byte) error) error {
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := io.ReadFull(src, buf)
+		if n > 0 {
+			if uerr := update(buf[:n]); uerr != nil {
+				return uerr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeFramed writes data to w as a 4-byte big-endian length prefix
+// followed by data itself.
+func writeFramed(w io.Writer, data []byte) error {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(data)))
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readFramed reads one length-prefixed frame written by writeFramed.
+func readFramed(r io.Reader) ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(lenBuf[:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,58 @@
+package ksema
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriteReadFramedRoundTrip(t *testing.T) {
+	frames := [][]byte{
+		[]byte("hello"),
+		{},
+		bytes.Repeat([]byte{0xAB}, 1<<16),
+	}
+
+	var buf bytes.Buffer
+	for _, f := range frames {
+		if err := writeFramed(&buf, f); err != nil {
+			t.Fatalf("writeFramed: %v", err)
+		}
+	}
+
+	for i, want := range frames {
+		got, err := readFramed(&buf)
+		if err != nil {
+			t.Fatalf("readFramed frame %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("frame %d = %v, want %v", i, got, want)
+		}
+	}
+
+	if _, err := readFramed(&buf); err != io.EOF {
+		t.Fatalf("readFramed after last frame = %v, want io.EOF", err)
+	}
+}
+
+func TestFeedStreamChunksInput(t *testing.T) {
+	data := bytes.Repeat([]byte{0x01}, streamChunkSize+10)
+
+	var chunks [][]byte
+	err := feedStream(bytes.NewReader(data), func(chunk []byte) error {
+		c := make([]byte, len(chunk))
+		copy(c, chunk)
+		chunks = append(chunks, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("feedStream: %v", err)
+	}
+
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	if len(chunks[0]) != streamChunkSize || len(chunks[1]) != 10 {
+		t.Fatalf("chunk sizes = %d, %d, want %d, 10", len(chunks[0]), len(chunks[1]), streamChunkSize)
+	}
+}